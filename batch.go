@@ -0,0 +1,78 @@
+package wialongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// BatchCall : a single `{svc, params}` entry of a core/batch request
+type BatchCall struct {
+	Svc    WialonAPISvc
+	Params WialonAPIParams
+}
+
+// BatchFlags : controls the `flags` parameter of core/batch, @see https://sdk.wialon.com/wiki/en/sidebar/remoteapi/apiref/core/batch
+type BatchFlags int
+
+const (
+	// BatchContinueOnError : keep executing the remaining calls after one of them fails
+	BatchContinueOnError BatchFlags = 0
+	// BatchStopOnError : abort the batch as soon as one call fails
+	BatchStopOnError BatchFlags = 1
+)
+
+// BatchResult : the per-call outcome of a Batch request. Raw always holds the
+// call's own raw result; Code/Msg are additionally populated when that call
+// reported a Wialon "error" code.
+type BatchResult struct {
+	Raw  json.RawMessage
+	Code WialonError
+	Msg  string
+}
+
+// Err : nil on success, otherwise a *WialonAPIError wrapping Code/Msg
+func (r BatchResult) Err() error {
+	if r.Code == 0 {
+		return nil
+	}
+	return &WialonAPIError{Op: "Batch", Code: r.Code, Err: errors.New(r.Msg)}
+}
+
+// Batch : execute several SVC calls in a single core/batch round trip. Each
+// BatchCall.Svc is expanded with the same unit_group/_→/ rewrite rules as
+// WialonAPICall, so callers compose calls the same way they would a single one,
+// e.g. "unit_group_update_units", "core_search_items".
+func (w *Wialon) Batch(ctx context.Context, calls []BatchCall, flags BatchFlags) ([]BatchResult, error) {
+	batch := make([]WialonAPIParams, 0, len(calls))
+	for _, c := range calls {
+		batch = append(batch, WialonAPIParams{
+			"svc":    expandSvc(c.Svc),
+			"params": c.Params,
+		})
+	}
+
+	result, err := w.WialonAPICall(ctx, "core_batch", WialonAPIParams{
+		"params": batch,
+		"flags":  int(flags),
+	})
+	if err != nil {
+		return nil, wrapAPIError("Batch", err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(result), &raw); err != nil {
+		return nil, &WialonAPIError{Op: "Batch", Err: fmt.Errorf("decode response: %w", err)}
+	}
+
+	results := make([]BatchResult, len(raw))
+	for i, item := range raw {
+		if code, msg, isErr := parseWialonError(WialonResult(item)); isErr {
+			results[i] = BatchResult{Raw: item, Code: code, Msg: msg}
+			continue
+		}
+		results[i] = BatchResult{Raw: item}
+	}
+	return results, nil
+}
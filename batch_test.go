@@ -0,0 +1,97 @@
+package wialongo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandSvc(t *testing.T) {
+	cases := []struct {
+		svc  WialonAPISvc
+		want string
+	}{
+		{"core_login", "core/login"},
+		{"token_login", "token/login"},
+		{"core_search_items", "core/search_items"},
+		{"unit_group_update_units", "unit_group/update_units"},
+		{"unit_group_create", "unit_group/create"},
+	}
+
+	for _, c := range cases {
+		if got := expandSvc(c.svc); got != c.want {
+			t.Errorf("expandSvc(%q) = %q, want %q", c.svc, got, c.want)
+		}
+	}
+}
+
+func TestBatchDecodesPerCallResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`[{"ok":true},{"error":1}]`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+
+	results, err := w.Batch(context.Background(), []BatchCall{
+		{Svc: "core_search_items", Params: WialonAPIParams{}},
+		{Svc: "unit_group_update_units", Params: WialonAPIParams{}},
+	}, BatchContinueOnError)
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("len(results) = %d, want 2", len(results))
+	}
+
+	if err := results[0].Err(); err != nil {
+		t.Errorf("results[0].Err() = %v, want nil", err)
+	}
+	if string(results[0].Raw) != `{"ok":true}` {
+		t.Errorf("results[0].Raw = %s, want {\"ok\":true}", results[0].Raw)
+	}
+
+	if err := results[1].Err(); err == nil {
+		t.Error("results[1].Err() = nil, want an error")
+	} else if results[1].Code != InvalidSession {
+		t.Errorf("results[1].Code = %d, want %d", results[1].Code, InvalidSession)
+	}
+}
+
+// TestBatchPreservesAPIErrorCode guards the whole-call error path (as opposed
+// to per-item BatchResult.Err, covered above): errors.As must still surface
+// the underlying WialonAPICall failure's Code through Batch's own Op.
+func TestBatchPreservesAPIErrorCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"error":1011}`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+
+	_, err := w.Batch(context.Background(), []BatchCall{
+		{Svc: "core_search_items", Params: WialonAPIParams{}},
+	}, BatchContinueOnError)
+	if err == nil {
+		t.Fatal("Batch() error = nil, want an IPChangedOrSessExpired error")
+	}
+
+	var apiErr *WialonAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatal("errors.As(err, &apiErr) = false, want true")
+	}
+	if apiErr.Op != "Batch" {
+		t.Errorf("apiErr.Op = %q, want Batch", apiErr.Op)
+	}
+	if apiErr.Code != IPChangedOrSessExpired {
+		t.Errorf("apiErr.Code = %d, want %d (IPChangedOrSessExpired)", apiErr.Code, IPChangedOrSessExpired)
+	}
+}
@@ -0,0 +1,78 @@
+package wialongo
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// binWriter : a sticky-error binary writer - once a write fails every later
+// call becomes a no-op, so a caller can fire off a sequence of writes and only
+// check err once at the end (mirrors the length-prefixed field layout SaveTo uses)
+type binWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (bw *binWriter) writeUint8(v uint8) {
+	if bw.err != nil {
+		return
+	}
+	bw.err = binary.Write(bw.w, binary.BigEndian, v)
+}
+
+func (bw *binWriter) writeInt64(v int64) {
+	if bw.err != nil {
+		return
+	}
+	bw.err = binary.Write(bw.w, binary.BigEndian, v)
+}
+
+func (bw *binWriter) writeString(s string) {
+	if bw.err != nil {
+		return
+	}
+	bw.err = binary.Write(bw.w, binary.BigEndian, uint32(len(s)))
+	if bw.err != nil {
+		return
+	}
+	_, bw.err = io.WriteString(bw.w, s)
+}
+
+// binReader : the sticky-error counterpart to binWriter
+type binReader struct {
+	r   io.Reader
+	err error
+}
+
+func (br *binReader) readUint8() uint8 {
+	if br.err != nil {
+		return 0
+	}
+	var v uint8
+	br.err = binary.Read(br.r, binary.BigEndian, &v)
+	return v
+}
+
+func (br *binReader) readInt64() int64 {
+	if br.err != nil {
+		return 0
+	}
+	var v int64
+	br.err = binary.Read(br.r, binary.BigEndian, &v)
+	return v
+}
+
+func (br *binReader) readString() string {
+	if br.err != nil {
+		return ""
+	}
+	var n uint32
+	if br.err = binary.Read(br.r, binary.BigEndian, &n); br.err != nil {
+		return ""
+	}
+	buf := make([]byte, n)
+	if _, br.err = io.ReadFull(br.r, buf); br.err != nil {
+		return ""
+	}
+	return string(buf)
+}
@@ -0,0 +1,48 @@
+package wialongo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBinWriterReaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	bw := &binWriter{w: &buf}
+	bw.writeUint8(7)
+	bw.writeString("hello")
+	bw.writeInt64(-12345)
+	bw.writeString("")
+	if bw.err != nil {
+		t.Fatalf("binWriter error = %v", bw.err)
+	}
+
+	br := &binReader{r: &buf}
+	if got := br.readUint8(); got != 7 {
+		t.Errorf("readUint8() = %d, want 7", got)
+	}
+	if got := br.readString(); got != "hello" {
+		t.Errorf("readString() = %q, want %q", got, "hello")
+	}
+	if got := br.readInt64(); got != -12345 {
+		t.Errorf("readInt64() = %d, want -12345", got)
+	}
+	if got := br.readString(); got != "" {
+		t.Errorf("readString() = %q, want empty", got)
+	}
+	if br.err != nil {
+		t.Fatalf("binReader error = %v", br.err)
+	}
+}
+
+func TestBinReaderStickyErrorStopsAtFirstFailure(t *testing.T) {
+	br := &binReader{r: bytes.NewReader(nil)}
+	if got := br.readUint8(); got != 0 {
+		t.Errorf("readUint8() = %d, want 0 on empty input", got)
+	}
+	if br.err == nil {
+		t.Fatal("br.err = nil, want an error after reading past EOF")
+	}
+	if got := br.readString(); got != "" {
+		t.Errorf("readString() after a failed read = %q, want empty (no-op)", got)
+	}
+}
@@ -0,0 +1,100 @@
+package wialongo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventsPollerDeliversEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"tm":1,"events":[{"i":42,"t":"unit_position","d":{"x":1}}]}`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+	w.Sid = "sid"
+
+	p := NewEventsPoller(w, "")
+	p.PollDelay = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+	defer p.Stop()
+
+	select {
+	case evt := <-p.Events():
+		if evt.UnitID != 42 {
+			t.Errorf("evt.UnitID = %d, want 42", evt.UnitID)
+		}
+		if evt.Type != "unit_position" {
+			t.Errorf("evt.Type = %q, want unit_position", evt.Type)
+		}
+	case err := <-p.Errors():
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+}
+
+// TestEventsPollerBacksOffOnFailedReLogin guards against a busy loop: when
+// both /avl_evts and token_login keep failing, the poller must pace retries
+// by PollDelay rather than hammering the endpoints at wire speed.
+func TestEventsPollerBacksOffOnFailedReLogin(t *testing.T) {
+	var loginCalls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/avl_evts" {
+			rw.Write([]byte(`{"error":1}`))
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		if values.Get("svc") == "token/login" {
+			atomic.AddInt32(&loginCalls, 1)
+		}
+		rw.Write([]byte(`{"error":8}`)) // InvalidUserNameOrPassword: login never succeeds
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+	w.Sid = "sid"
+
+	p := NewEventsPoller(w, "bad-token")
+	p.PollDelay = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+	go func() {
+		for range p.Errors() {
+		}
+	}()
+
+	const window = 150 * time.Millisecond
+	time.Sleep(window)
+	p.Stop()
+
+	calls := atomic.LoadInt32(&loginCalls)
+	if calls == 0 {
+		t.Fatal("login attempts = 0, want at least one")
+	}
+	// A 20ms backoff over a 150ms window allows for roughly window/PollDelay
+	// attempts; a busy loop would rack up orders of magnitude more.
+	if max := int32(window/p.PollDelay) + 3; calls > max {
+		t.Errorf("login attempts = %d in %s, want <= %d (busy-loop regression)", calls, window, max)
+	}
+}
@@ -0,0 +1,238 @@
+package wialongo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger : the tracing hook WialonAPICall reports through instead of fmt.Println;
+// a Wialon with no Logger set discards everything
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// noopLogger : the default Logger, discards everything
+type noopLogger struct{}
+
+func (noopLogger) Debugf(string, ...interface{}) {}
+func (noopLogger) Infof(string, ...interface{})  {}
+func (noopLogger) Errorf(string, ...interface{}) {}
+
+// StdLogger : a Logger backed by the standard library's log package
+type StdLogger struct {
+	*log.Logger
+}
+
+// NewStdLogger : a StdLogger writing to stderr, prefixed "wialongo: "
+func NewStdLogger() *StdLogger {
+	return &StdLogger{log.New(os.Stderr, "wialongo: ", log.LstdFlags)}
+}
+
+// Debugf : implements Logger
+func (l *StdLogger) Debugf(format string, args ...interface{}) { l.Printf("DEBUG "+format, args...) }
+
+// Infof : implements Logger
+func (l *StdLogger) Infof(format string, args ...interface{}) { l.Printf("INFO "+format, args...) }
+
+// Errorf : implements Logger
+func (l *StdLogger) Errorf(format string, args ...interface{}) { l.Printf("ERROR "+format, args...) }
+
+func (w *Wialon) logger() Logger {
+	if w.Logger == nil {
+		return noopLogger{}
+	}
+	return w.Logger
+}
+
+// Next : the remainder of the middleware chain a RoundTripFunc can call into
+type Next func(req *http.Request) (*http.Response, error)
+
+// RoundTripFunc : a single link of Wialon's middleware chain, in the spirit of
+// chi.Router.Use - it gets the request and the rest of the chain, and decides
+// whether/how to call it
+type RoundTripFunc func(req *http.Request, next Next) (*http.Response, error)
+
+// Use : register middlewares to run, in order, around every HTTP request Wialon
+// makes (WialonAPICall and EventsPoller alike)
+func (w *Wialon) Use(mw ...RoundTripFunc) {
+	w.middlewares = append(w.middlewares, mw...)
+}
+
+// httpClient : the *http.Client requests are ultimately sent through, defaulting
+// to one with defaultHTTPTimeout if the caller never set HTTPClient
+func (w *Wialon) httpClient() *http.Client {
+	if w.HTTPClient == nil {
+		w.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return w.HTTPClient
+}
+
+// do runs req through the registered middleware chain, terminating in HTTPClient.Do
+func (w *Wialon) do(req *http.Request) (*http.Response, error) {
+	next := Next(w.httpClient().Do)
+	for i := len(w.middlewares) - 1; i >= 0; i-- {
+		mw := w.middlewares[i]
+		prevNext := next
+		next = func(r *http.Request) (*http.Response, error) {
+			return mw(r, prevNext)
+		}
+	}
+	return next(req)
+}
+
+// RetryMiddleware makes up to maxAttempts attempts at a request (the first try
+// included), backing off exponentially starting at backoff, whenever Wialon reports OneReqAllowed or
+// ExecutionTimeExceeded, the server answers with a 5xx status, or the transport
+// itself errors.
+func RetryMiddleware(maxAttempts int, backoff time.Duration) RoundTripFunc {
+	return func(req *http.Request, next Next) (*http.Response, error) {
+		var lastErr error
+		delay := backoff
+		for attempt := 0; attempt < maxAttempts; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(delay):
+				}
+				delay *= 2
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if resp.StatusCode >= http.StatusInternalServerError {
+				resp.Body.Close()
+				lastErr = fmt.Errorf("server error: %s", resp.Status)
+				continue
+			}
+
+			body, readErr := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				lastErr = readErr
+				continue
+			}
+			if code, msg, isErr := parseWialonError(WialonResult(body)); isErr && (code == OneReqAllowed || code == ExecutionTimeExceeded) {
+				lastErr = &WialonAPIError{Op: "RetryMiddleware", Code: code, Err: errors.New(msg)}
+				continue
+			}
+
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			return resp, nil
+		}
+		return nil, lastErr
+	}
+}
+
+// ReLoginMiddleware re-authenticates with token and retries the request once,
+// with a refreshed sid, whenever Wialon reports InvalidSession or
+// IPChangedOrSessExpired.
+func ReLoginMiddleware(w *Wialon, token WialonToken) RoundTripFunc {
+	return func(req *http.Request, next Next) (*http.Response, error) {
+		resp, err := next(req)
+		if err != nil {
+			return resp, err
+		}
+
+		body, readErr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		code, _, isErr := parseWialonError(WialonResult(body))
+		if !isErr || (code != InvalidSession && code != IPChangedOrSessExpired) {
+			return resp, nil
+		}
+
+		if _, loginErr := w.Login(req.Context(), token); loginErr != nil {
+			return resp, &WialonAPIError{Op: "ReLoginMiddleware", Err: fmt.Errorf("re-login after %s: %w", code, loginErr)}
+		}
+
+		retryReq, err := cloneRequestWithSid(req, w.sid())
+		if err != nil {
+			return resp, err
+		}
+		return next(retryReq)
+	}
+}
+
+// cloneRequestWithSid rebuilds a form-encoded request body with an updated sid,
+// since the original body was already encoded before the session expired
+func cloneRequestWithSid(req *http.Request, sid string) (*http.Request, error) {
+	if req.Body == nil {
+		return req, nil
+	}
+	// req.Body was drained by the first attempt; GetBody yields a fresh copy
+	body := req.Body
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	raw, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil, err
+	}
+	values.Set("sid", sid)
+	encoded := values.Encode()
+
+	clone := req.Clone(req.Context())
+	clone.Body = ioutil.NopCloser(strings.NewReader(encoded))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(encoded)), nil
+	}
+	clone.ContentLength = int64(len(encoded))
+	return clone, nil
+}
+
+// RateLimitMiddleware throttles requests to at most one every interval,
+// honoring Wialon's per-second request limits
+func RateLimitMiddleware(interval time.Duration) RoundTripFunc {
+	var mu sync.Mutex
+	var last time.Time
+
+	return func(req *http.Request, next Next) (*http.Response, error) {
+		// reserve the next free slot under mu so concurrent callers queue up
+		// one interval apart instead of all waking at the same moment
+		mu.Lock()
+		slot := last.Add(interval)
+		if now := time.Now(); slot.Before(now) {
+			slot = now
+		}
+		last = slot
+		mu.Unlock()
+
+		if wait := time.Until(slot); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+		return next(req)
+	}
+}
@@ -0,0 +1,153 @@
+package wialongo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&calls, 1) < 3 {
+			rw.Write([]byte(`{"error":1003}`))
+			return
+		}
+		rw.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+	w.Use(RetryMiddleware(5, time.Millisecond))
+
+	result, err := w.WialonAPICall(context.Background(), "core_search_items", WialonAPIParams{})
+	if err != nil {
+		t.Fatalf("WialonAPICall() error = %v", err)
+	}
+	if string(result) != `{"ok":true}` {
+		t.Errorf("result = %s, want {\"ok\":true}", result)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestRetryMiddlewareGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"error":1005}`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+	w.Use(RetryMiddleware(2, time.Millisecond))
+
+	if _, err := w.WialonAPICall(context.Background(), "core_search_items", WialonAPIParams{}); err == nil {
+		t.Fatal("WialonAPICall() error = nil, want a persistent ExecutionTimeExceeded error")
+	}
+}
+
+func TestReLoginMiddleware(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		rw.Header().Set("Content-Type", "application/json")
+
+		if values.Get("svc") == "token/login" {
+			rw.Write([]byte(`{"eid":"NEWSID"}`))
+			return
+		}
+
+		if atomic.AddInt32(&calls, 1) == 1 {
+			rw.Write([]byte(`{"error":1}`))
+			return
+		}
+		if sid := values.Get("sid"); sid != "NEWSID" {
+			t.Errorf("retried request sid = %q, want NEWSID", sid)
+		}
+		rw.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.Sid = "STALE"
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+	w.Use(ReLoginMiddleware(w, "sometoken"))
+
+	result, err := w.WialonAPICall(context.Background(), "core_search_items", WialonAPIParams{})
+	if err != nil {
+		t.Fatalf("WialonAPICall() error = %v", err)
+	}
+	if string(result) != `{"ok":true}` {
+		t.Errorf("result = %s, want {\"ok\":true}", result)
+	}
+}
+
+func TestRateLimitMiddlewareSpacesCalls(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	const interval = 100 * time.Millisecond
+	const tolerance = 10 * time.Millisecond
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+	w.Use(RateLimitMiddleware(interval))
+
+	if _, err := w.WialonAPICall(context.Background(), "core_search_items", WialonAPIParams{}); err != nil {
+		t.Fatalf("WialonAPICall() #1 error = %v", err)
+	}
+
+	start := time.Now()
+	if _, err := w.WialonAPICall(context.Background(), "core_search_items", WialonAPIParams{}); err != nil {
+		t.Fatalf("WialonAPICall() #2 error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < interval-tolerance {
+		t.Errorf("second call returned after %s, want roughly >= %s (interval)", elapsed, interval)
+	}
+}
+
+func TestRateLimitMiddlewareRespectsContextCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	const interval = time.Hour
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+	w.Use(RateLimitMiddleware(interval))
+
+	if _, err := w.WialonAPICall(context.Background(), "core_search_items", WialonAPIParams{}); err != nil {
+		t.Fatalf("WialonAPICall() #1 error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := w.WialonAPICall(ctx, "core_search_items", WialonAPIParams{})
+	if err == nil {
+		t.Fatal("WialonAPICall() error = nil, want context deadline exceeded while waiting out the interval")
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("call blocked for %s, want it to return once the context was done well before %s", elapsed, interval)
+	}
+}
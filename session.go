@@ -0,0 +1,109 @@
+package wialongo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// sessionRecordVersion : bumped whenever the SaveTo/LoadFrom wire format changes
+const sessionRecordVersion uint8 = 1
+
+// SessionStore persists and restores Wialon session state across process
+// restarts, so a caller doesn't have to spend a fresh token_login (and its
+// quota) on every start. Login writes to it on success; a WialonAPICall that
+// comes back InvalidSession invalidates it.
+type SessionStore interface {
+	// Save persists w's current session
+	Save(ctx context.Context, w *Wialon) error
+	// Load restores a previously saved session into w
+	Load(ctx context.Context, w *Wialon) error
+	// Invalidate discards whatever session is currently stored
+	Invalidate(ctx context.Context) error
+}
+
+// MarshalBinary : encodes Sid, BaseAPIUrl, DefaultParams, the last successful
+// login time, and the WialonToken used for it, see SaveTo for the wire format
+func (w *Wialon) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := w.SaveTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary : the inverse of MarshalBinary
+func (w *Wialon) UnmarshalBinary(data []byte) error {
+	return w.LoadFrom(bytes.NewReader(data))
+}
+
+// SaveTo writes a versioned, length-prefixed session record for w to dst.
+//
+// Scope note: the WialonToken is always written as plaintext - this package
+// does not implement its own encryption. A SessionStore backend that needs
+// it encrypted at rest (e.g. because the file/DB isn't otherwise
+// access-controlled) must encrypt the bytes SaveTo/MarshalBinary produce
+// itself before persisting them.
+func (w *Wialon) SaveTo(dst io.Writer) error {
+	w.mu.Lock()
+	sid, baseAPIURL, lastLogin, token := w.Sid, w.BaseAPIUrl, w.lastLogin, w.token
+	persistedParams := make(WialonAPIParams, len(w.DefaultParams))
+	for k, v := range w.DefaultParams {
+		persistedParams[k] = v
+	}
+	w.mu.Unlock()
+	for _, k := range reqParamKeys {
+		delete(persistedParams, k)
+	}
+
+	params, err := json.Marshal(persistedParams)
+	if err != nil {
+		return fmt.Errorf("wialongo: SaveTo: marshal default params: %w", err)
+	}
+
+	bw := &binWriter{w: dst}
+	bw.writeUint8(sessionRecordVersion)
+	bw.writeString(sid)
+	bw.writeString(baseAPIURL)
+	bw.writeString(string(params))
+	bw.writeInt64(lastLogin.Unix())
+	bw.writeString(string(token))
+	if bw.err != nil {
+		return fmt.Errorf("wialongo: SaveTo: %w", bw.err)
+	}
+	return nil
+}
+
+// LoadFrom reads a record written by SaveTo/MarshalBinary back into w
+func (w *Wialon) LoadFrom(src io.Reader) error {
+	br := &binReader{r: src}
+	version := br.readUint8()
+	sid := br.readString()
+	baseAPIURL := br.readString()
+	params := br.readString()
+	lastLogin := br.readInt64()
+	token := br.readString()
+	if br.err != nil {
+		return fmt.Errorf("wialongo: LoadFrom: %w", br.err)
+	}
+	if version != sessionRecordVersion {
+		return fmt.Errorf("wialongo: LoadFrom: unsupported session record version %d", version)
+	}
+
+	var defaultParams WialonAPIParams
+	if err := json.Unmarshal([]byte(params), &defaultParams); err != nil {
+		return fmt.Errorf("wialongo: LoadFrom: unmarshal default params: %w", err)
+	}
+
+	w.mu.Lock()
+	w.Sid = sid
+	w.BaseAPIUrl = baseAPIURL
+	w.DefaultParams = defaultParams
+	w.lastLogin = time.Unix(lastLogin, 0)
+	w.token = WialonToken(token)
+	w.mu.Unlock()
+	return nil
+}
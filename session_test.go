@@ -0,0 +1,107 @@
+package wialongo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWialonSaveToLoadFromRoundTrip(t *testing.T) {
+	w := NewDefaultWialon()
+	w.Sid = "ABC123"
+	w.DefaultParams = WialonAPIParams{"locale": "en"}
+	w.token = "sometoken"
+
+	var buf bytes.Buffer
+	if err := w.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo() error = %v", err)
+	}
+
+	got := new(Wialon)
+	if err := got.LoadFrom(&buf); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+
+	if got.Sid != w.Sid {
+		t.Errorf("Sid = %q, want %q", got.Sid, w.Sid)
+	}
+	if got.BaseAPIUrl != w.BaseAPIUrl {
+		t.Errorf("BaseAPIUrl = %q, want %q", got.BaseAPIUrl, w.BaseAPIUrl)
+	}
+	if got.DefaultParams["locale"] != "en" {
+		t.Errorf("DefaultParams[locale] = %v, want en", got.DefaultParams["locale"])
+	}
+	if got.token != w.token {
+		t.Errorf("token = %q, want %q", got.token, w.token)
+	}
+}
+
+// TestWialonSaveToFiltersPerCallParams guards against a login token (or any
+// other call's params) leaking into a persisted session record: WialonAPICall
+// merges "sid"/"svc"/"params" into DefaultParams on every call, and SaveTo
+// must filter those back out rather than persisting them verbatim.
+func TestWialonSaveToFiltersPerCallParams(t *testing.T) {
+	w := NewDefaultWialon()
+	w.Sid = "ABC123"
+	w.DefaultParams = WialonAPIParams{
+		"locale": "en",
+		"sid":    "ABC123",
+		"svc":    "token/login",
+		"params": map[string]interface{}{"token": "supersecret"},
+	}
+
+	var buf bytes.Buffer
+	if err := w.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo() error = %v", err)
+	}
+
+	got := new(Wialon)
+	if err := got.LoadFrom(&buf); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+
+	if got.DefaultParams["locale"] != "en" {
+		t.Errorf("DefaultParams[locale] = %v, want en", got.DefaultParams["locale"])
+	}
+	for _, k := range []string{"sid", "svc", "params"} {
+		if _, ok := got.DefaultParams[k]; ok {
+			t.Errorf("DefaultParams[%q] persisted, want it filtered out", k)
+		}
+	}
+}
+
+func TestWialonMarshalUnmarshalBinaryRoundTrip(t *testing.T) {
+	w := NewDefaultWialon()
+	w.Sid = "XYZ"
+
+	data, err := w.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	got := new(Wialon)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if got.Sid != w.Sid {
+		t.Errorf("Sid = %q, want %q", got.Sid, w.Sid)
+	}
+}
+
+func TestWialonLoadFromRejectsUnsupportedVersion(t *testing.T) {
+	var buf bytes.Buffer
+	bw := &binWriter{w: &buf}
+	bw.writeUint8(sessionRecordVersion + 1)
+	bw.writeString("sid")
+	bw.writeString("url")
+	bw.writeString("{}")
+	bw.writeInt64(0)
+	bw.writeString("")
+	if bw.err != nil {
+		t.Fatalf("binWriter error = %v", bw.err)
+	}
+
+	got := new(Wialon)
+	if err := got.LoadFrom(&buf); err == nil {
+		t.Fatal("LoadFrom() error = nil, want a version mismatch error")
+	}
+}
@@ -0,0 +1,74 @@
+// Package sessionstore holds SessionStore backends for wialongo.Wialon;
+// FileStore is the bundled one, callers can plug in their own (Redis, a DB,
+// ...) by implementing wialongo.SessionStore directly.
+package sessionstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/rojaster/wialongo"
+)
+
+// FileStore saves a Wialon session record to a file on disk, guarded by a
+// mutex so concurrent Save/Load/Invalidate calls on one FileStore don't race
+// on its path; separate FileStores sharing a path are not serialised.
+type FileStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileStore : a FileStore persisting session records at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Save implements wialongo.SessionStore
+func (s *FileStore) Save(ctx context.Context, w *wialongo.Wialon) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("sessionstore: FileStore.Save: %w", err)
+	}
+	defer f.Close()
+
+	if err := w.SaveTo(f); err != nil {
+		return fmt.Errorf("sessionstore: FileStore.Save: %w", err)
+	}
+	return nil
+}
+
+// Load implements wialongo.SessionStore
+func (s *FileStore) Load(ctx context.Context, w *wialongo.Wialon) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return fmt.Errorf("sessionstore: FileStore.Load: %w", err)
+	}
+	defer f.Close()
+
+	if err := w.LoadFrom(f); err != nil {
+		return fmt.Errorf("sessionstore: FileStore.Load: %w", err)
+	}
+	return nil
+}
+
+// Invalidate implements wialongo.SessionStore by removing the stored record
+func (s *FileStore) Invalidate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("sessionstore: FileStore.Invalidate: %w", err)
+	}
+	return nil
+}
+
+var _ wialongo.SessionStore = (*FileStore)(nil)
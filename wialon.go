@@ -13,13 +13,17 @@ package wialongo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // WialonError : type aliasing
@@ -40,10 +44,10 @@ type WialonAPIParams map[string]interface{}
 
 // WialonAPI : type aliasing(interface) of wialon struct
 type WialonAPI interface {
-	Logout() (WialonResult, bool) // re-check perhaps it's better return a json object
-	Login(token string) (WialonResult, bool)
+	Logout(ctx context.Context) (WialonResult, error)
+	Login(ctx context.Context, token WialonToken) (WialonResult, error)
 	UpdateExtraParams(params WialonAPIParams)
-	WialonAPICall(action WialonAPISvc, args WialonAPIParams) WialonResult
+	WialonAPICall(ctx context.Context, action WialonAPISvc, args WialonAPIParams) (WialonResult, error)
 }
 
 // Wialon : base struct of Wialon API
@@ -51,8 +55,30 @@ type Wialon struct {
 	Sid           string
 	BaseAPIUrl    string
 	DefaultParams WialonAPIParams
+
+	// HTTPClient : performs the actual requests; defaults to a client with
+	// defaultHTTPTimeout, set by NewWialon/NewDefaultWialon
+	HTTPClient *http.Client
+	// Logger : receives request tracing, nil (the default) discards it
+	Logger Logger
+	// Store : if set, Login persists the session to it and a WialonAPICall
+	// reporting InvalidSession invalidates it; see SessionStore
+	Store SessionStore
+
+	middlewares []RoundTripFunc
+	token       WialonToken
+	lastLogin   time.Time
+
+	// mu : guards Sid/DefaultParams, the fields WialonAPICall/Login/Logout read
+	// and write. A *Wialon is meant to be shared - EventsPoller in particular
+	// runs its own goroutine against one a caller keeps using elsewhere - so
+	// those accesses all have to go through it.
+	mu sync.Mutex
 }
 
+// defaultHTTPTimeout : applied to the HTTPClient a freshly constructed Wialon gets
+const defaultHTTPTimeout = 30 * time.Second
+
 // WialonErrors
 const (
 	InvalidSession WialonError = iota + 1
@@ -99,10 +125,52 @@ var (
 	}
 )
 
-func (we WialonError) String() string {
+// Error : lets WialonError be compared directly via errors.Is(err, wialongo.InvalidSession)
+func (we WialonError) Error() string {
 	return fmt.Sprintf("%d : %s", we, WialonErrors[we])
 }
 
+func (we WialonError) String() string {
+	return we.Error()
+}
+
+// WialonAPIError : wraps a failed WialonAPI call, whether it failed in transport/JSON
+// decoding (Err set, Code zero) or because Wialon reported an "error" code in the
+// response body (Code/Err both set to the matching WialonError and its message).
+// Callers can errors.As(err, &wialonAPIErr) to get at Op/Code, or errors.Is(err, InvalidSession)
+// to check for a specific code directly.
+type WialonAPIError struct {
+	Op   string // the method that failed, e.g. "WialonAPICall", "Login"
+	Code WialonError
+	Err  error
+}
+
+func (e *WialonAPIError) Error() string {
+	return fmt.Sprintf("wialongo: %s: %s", e.Op, e.Err)
+}
+
+func (e *WialonAPIError) Unwrap() error {
+	return e.Err
+}
+
+// Is : allows errors.Is(err, SomeWialonErrorConst) to match on Code
+func (e *WialonAPIError) Is(target error) bool {
+	code, ok := target.(WialonError)
+	return ok && e.Code == code
+}
+
+// wrapAPIError : tags err as having failed in op. If err is already a
+// *WialonAPIError (e.g. returned by WialonAPICall), its Code is carried over
+// so errors.As(err, &apiErr) still sees it through the new Op - wrapping it
+// bare would bury Code behind a second, zero-Code layer.
+func wrapAPIError(op string, err error) error {
+	var apiErr *WialonAPIError
+	if errors.As(err, &apiErr) {
+		return &WialonAPIError{Op: op, Code: apiErr.Code, Err: err}
+	}
+	return &WialonAPIError{Op: op, Err: err}
+}
+
 // NewDefaultWialon : create Wialon object with default parameters
 func NewDefaultWialon() *Wialon {
 	return NewWialon("https", "hst-api.wialon.com", "", "", map[string]interface{}{})
@@ -119,75 +187,108 @@ func NewWialon(scheme string, host string, port string, sid string, extraParams
 	w.Sid = sid
 	w.BaseAPIUrl = fmt.Sprintf("%s://%s%s/wialon/ajax.html?", scheme, host, port)
 	w.DefaultParams = extraParams
+	w.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
 	return w
 }
 
 // Login : login into wialon and get sid
-func (w *Wialon) Login(token WialonToken) (WialonResult, bool) {
-	var result WialonResult
-	data := map[string]interface{}{
+func (w *Wialon) Login(ctx context.Context, token WialonToken) (WialonResult, error) {
+	result, err := w.WialonAPICall(ctx, "token_login", WialonAPIParams{
 		"token": string(token), // it will be url encoded later in api call
+	})
+	if err != nil {
+		return result, wrapAPIError("Login", err)
 	}
-	result = w.WialonAPICall("token_login", data)
 
 	var jsonResult = WialonAPIParams{}
-	json.Unmarshal([]byte(result), &jsonResult)
+	if err := json.Unmarshal([]byte(result), &jsonResult); err != nil {
+		return result, &WialonAPIError{Op: "Login", Err: fmt.Errorf("decode response: %w", err)}
+	}
 
 	// @Information(alekum 28/04/2019): if eid somewhere in the future is not a string we will get an empty sid every time
 	if eid, ok := jsonResult["eid"].(string); ok {
+		w.mu.Lock()
 		w.Sid = eid
-		return result, true
+		w.token = token
+		w.lastLogin = time.Now()
+		w.mu.Unlock()
+		if w.Store != nil {
+			if saveErr := w.Store.Save(ctx, w); saveErr != nil {
+				w.logger().Errorf("save session: %s", saveErr)
+			}
+		}
+		return result, nil
 	}
-	return result, false
+	return result, &WialonAPIError{Op: "Login", Err: errors.New("response did not contain an eid")}
 }
 
 // Logout : logout from wialon and discard sid
-func (w *Wialon) Logout() (WialonResult, bool) {
-	result := w.WialonAPICall("core_logout", WialonAPIParams{})
-
-	var jsonResult = map[string]interface{}{}
-	json.Unmarshal([]byte(result), &jsonResult)
-
-	if err, ok := jsonResult["error"].(float64); ok && int(err) == 0 {
-		w.Sid = ""
-		return result, true
+func (w *Wialon) Logout(ctx context.Context) (WialonResult, error) {
+	result, err := w.WialonAPICall(ctx, "core_logout", WialonAPIParams{})
+	if err != nil {
+		return result, wrapAPIError("Logout", err)
 	}
-	return result, false
+	w.mu.Lock()
+	w.Sid = ""
+	w.mu.Unlock()
+	return result, nil
 }
 
 // UpdateExtraParams : resolve some parameters for SVC command, replace current default params
 func (w *Wialon) UpdateExtraParams(params WialonAPIParams) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.updateExtraParamsLocked(params)
+}
+
+// updateExtraParamsLocked : the body of UpdateExtraParams, for call sites that
+// already hold mu (WialonAPICall merges reqParams in under its own lock)
+func (w *Wialon) updateExtraParamsLocked(params WialonAPIParams) {
 	for k, v := range params {
 		w.DefaultParams[k] = v
 	}
 }
 
+// sid : read Sid under mu, for goroutines (EventsPoller) that don't otherwise
+// touch Wialon's locked state
+func (w *Wialon) sid() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Sid
+}
+
+// reqParamKeys : the per-call keys WialonAPICall merges into DefaultParams
+// below (see reqParams) - not actual configuration, so SaveTo filters them
+// back out before persisting a session record.
+var reqParamKeys = []string{"sid", "svc", "params"}
+
 // WialonAPICall : actual call of the remote wialon api, It's the same as a call function in php lib
-func (w *Wialon) WialonAPICall(action WialonAPISvc, params WialonAPIParams) WialonResult {
-	svc := string(action)
+func (w *Wialon) WialonAPICall(ctx context.Context, action WialonAPISvc, params WialonAPIParams) (WialonResult, error) {
+	svc := expandSvc(action)
 	contentType := "application/x-www-form-urlencoded"
-	if strings.HasPrefix(svc, "unit_group") {
-		svc = svc[0:len("unit_group")] + "/" + svc[len("unit_group")+1:]
-	} else {
-		svc = strings.Replace(svc, "_", "/", 1)
-	}
 
 	// @Cleanup(alekum 28/04/2019):
 	// Perhaps manual json marshalling is a better way to do that due to natura of json-like data structures
+	w.mu.Lock()
 	reqParams := WialonAPIParams{
 		"sid":    w.Sid,
 		"svc":    svc,
 		"params": params,
 	}
-	w.UpdateExtraParams(reqParams)
+	w.updateExtraParamsLocked(reqParams)
+	snapshotParams := make(WialonAPIParams, len(w.DefaultParams))
+	for k, v := range w.DefaultParams {
+		snapshotParams[k] = v
+	}
+	w.mu.Unlock()
 
 	u, err := url.Parse(w.BaseAPIUrl)
 	if err != nil {
-		panic("Cannot parse Wialon baseAPIUrl")
+		return "", &WialonAPIError{Op: "WialonAPICall", Err: fmt.Errorf("parse base api url: %w", err)}
 	}
 
 	urlValues := url.Values{}
-	for k, v := range w.DefaultParams {
+	for k, v := range snapshotParams {
 		switch val := v.(type) {
 		case string:
 			// @Cleanup(alekum 29/04/2019):
@@ -202,32 +303,71 @@ func (w *Wialon) WialonAPICall(action WialonAPISvc, params WialonAPIParams) Wial
 		default:
 			jsonified, err := json.Marshal(val)
 			if err != nil {
-				panic("Cannot Marshal params")
+				return "", &WialonAPIError{Op: "WialonAPICall", Err: fmt.Errorf("marshal param %q: %w", k, err)}
 			}
 			urlValues.Set(k, string(jsonified))
 		}
 	}
 	u.RawQuery = urlValues.Encode()
 
-	fmt.Println(fmt.Sprintf("\n\t> APIUrl: %s,\n\tCType: %s,\n\tQParams: %s,\n\tMapParams: %s\n", u.Hostname()+u.Path, contentType, u.RawQuery, w.DefaultParams))
-	response, err := http.Post(w.BaseAPIUrl, contentType, bytes.NewBufferString(u.RawQuery))
+	w.logger().Debugf("\n\t> APIUrl: %s,\n\tCType: %s,\n\tQParams: %s,\n\tMapParams: %s\n", u.Hostname()+u.Path, contentType, u.RawQuery, snapshotParams)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.BaseAPIUrl, bytes.NewBufferString(u.RawQuery))
 	if err != nil {
-		panic("Cannot do Post Query")
+		return "", &WialonAPIError{Op: "WialonAPICall", Err: fmt.Errorf("build request: %w", err)}
 	}
+	req.Header.Set("Content-Type", contentType)
+
+	response, err := w.do(req)
+	if err != nil {
+		return "", &WialonAPIError{Op: "WialonAPICall", Err: fmt.Errorf("perform request: %w", err)}
+	}
+	defer response.Body.Close()
 
 	responseBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		panic("Cannot read response body")
+		return "", &WialonAPIError{Op: "WialonAPICall", Err: fmt.Errorf("read response body: %w", err)}
+	}
+
+	result := WialonResult(responseBody)
+	if code, msg, isErr := parseWialonError(result); isErr {
+		if code == InvalidSession && w.Store != nil {
+			if invErr := w.Store.Invalidate(ctx); invErr != nil {
+				w.logger().Errorf("invalidate session: %s", invErr)
+			}
+		}
+		return result, &WialonAPIError{Op: "WialonAPICall", Code: code, Err: errors.New(msg)}
 	}
-	return WialonResult(responseBody)
+	return result, nil
 }
 
-// ErrorHandler : a helper function to deal with wialon erros
-func ErrorHandler(res WialonResult) (we WialonError, msg string) {
+// expandSvc rewrites a WialonAPISvc such as "unit_group_update_units" or
+// "core_search_items" into the wire format Wialon expects ("unit_group/update_units",
+// "core/search_items"), i.e. the first underscore becomes a slash, except that
+// "unit_group_*" keeps "unit_group" intact since it itself contains an underscore.
+func expandSvc(action WialonAPISvc) string {
+	svc := string(action)
+	if strings.HasPrefix(svc, "unit_group") {
+		return svc[0:len("unit_group")] + "/" + svc[len("unit_group")+1:]
+	}
+	return strings.Replace(svc, "_", "/", 1)
+}
+
+// parseWialonError inspects a raw Wialon response and reports whether it carries
+// a non-zero top-level "error" field, along with the matching WialonError code
+// and its description from the WialonErrors map.
+func parseWialonError(res WialonResult) (code WialonError, msg string, ok bool) {
 	var errResult = map[string]interface{}{}
 	json.Unmarshal([]byte(res), &errResult)
-	loginError, _ := errResult["error"].(float64)
-	we = WialonError(int(loginError))
-	msg = WialonErrors[we]
+	if errVal, has := errResult["error"].(float64); has && int(errVal) != 0 {
+		code = WialonError(int(errVal))
+		return code, WialonErrors[code], true
+	}
+	return 0, "", false
+}
+
+// ErrorHandler : a helper function to deal with wialon erros
+func ErrorHandler(res WialonResult) (we WialonError, msg string) {
+	we, msg, _ = parseWialonError(res)
 	return we, msg
 }
@@ -0,0 +1,39 @@
+package wialongo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestWialonConcurrentAccess exercises the scenario EventsPoller is built for:
+// a *Wialon shared between a caller doing its own WialonAPICall/Login and a
+// background goroutine doing the same. Run with -race; it used to trip
+// "concurrent map writes" on the unsynchronized DefaultParams map.
+func TestWialonConcurrentAccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"eid":"sid"}`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			w.WialonAPICall(context.Background(), "core_search_items", WialonAPIParams{})
+		}()
+		go func() {
+			defer wg.Done()
+			w.Login(context.Background(), "token")
+		}()
+	}
+	wg.Wait()
+}
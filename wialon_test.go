@@ -0,0 +1,121 @@
+package wialongo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseWialonError(t *testing.T) {
+	cases := []struct {
+		name     string
+		res      WialonResult
+		wantCode WialonError
+		wantMsg  string
+		wantOk   bool
+	}{
+		{
+			name:   "no error field",
+			res:    `{"eid": "abc"}`,
+			wantOk: false,
+		},
+		{
+			name:   "error zero",
+			res:    `{"error": 0}`,
+			wantOk: false,
+		},
+		{
+			name:     "invalid session",
+			res:      `{"error": 1}`,
+			wantCode: InvalidSession,
+			wantMsg:  WialonErrors[InvalidSession],
+			wantOk:   true,
+		},
+		{
+			name:     "ip changed or session expired",
+			res:      `{"error": 1011}`,
+			wantCode: IPChangedOrSessExpired,
+			wantMsg:  WialonErrors[IPChangedOrSessExpired],
+			wantOk:   true,
+		},
+		{
+			name:   "not json",
+			res:    `not json at all`,
+			wantOk: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			code, msg, ok := parseWialonError(c.res)
+			if ok != c.wantOk {
+				t.Fatalf("ok = %v, want %v", ok, c.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if code != c.wantCode {
+				t.Errorf("code = %d, want %d", code, c.wantCode)
+			}
+			if msg != c.wantMsg {
+				t.Errorf("msg = %q, want %q", msg, c.wantMsg)
+			}
+		})
+	}
+}
+
+func TestWialonAPIErrorIs(t *testing.T) {
+	err := error(&WialonAPIError{Op: "WialonAPICall", Code: InvalidSession, Err: errors.New(WialonErrors[InvalidSession])})
+
+	if !errors.Is(err, InvalidSession) {
+		t.Error("errors.Is(err, InvalidSession) = false, want true")
+	}
+	if errors.Is(err, IPChangedOrSessExpired) {
+		t.Error("errors.Is(err, IPChangedOrSessExpired) = true, want false")
+	}
+
+	var apiErr *WialonAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatal("errors.As(err, &apiErr) = false, want true")
+	}
+	if apiErr.Code != InvalidSession {
+		t.Errorf("apiErr.Code = %d, want %d", apiErr.Code, InvalidSession)
+	}
+}
+
+// TestLoginPreservesAPIErrorCode guards against re-wrapping WialonAPICall's
+// already-typed error behind a fresh, zero-Code *WialonAPIError: errors.As
+// must still surface the original Code (InvalidSession here) through Login's
+// own Op.
+func TestLoginPreservesAPIErrorCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"error":1}`))
+	}))
+	defer srv.Close()
+
+	w := NewDefaultWialon()
+	w.BaseAPIUrl = srv.URL + "/wialon/ajax.html?"
+	w.HTTPClient = srv.Client()
+
+	_, err := w.Login(context.Background(), "token")
+	if err == nil {
+		t.Fatal("Login() error = nil, want an InvalidSession error")
+	}
+
+	var apiErr *WialonAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatal("errors.As(err, &apiErr) = false, want true")
+	}
+	if apiErr.Op != "Login" {
+		t.Errorf("apiErr.Op = %q, want Login", apiErr.Op)
+	}
+	if apiErr.Code != InvalidSession {
+		t.Errorf("apiErr.Code = %d, want %d (InvalidSession)", apiErr.Code, InvalidSession)
+	}
+	if !errors.Is(err, InvalidSession) {
+		t.Error("errors.Is(err, InvalidSession) = false, want true")
+	}
+}